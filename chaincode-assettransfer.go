@@ -10,14 +10,22 @@ import (
 	"strings"
 	"strconv"
 	"encoding/json"
+	"encoding/hex"
+	"crypto/sha256"
+	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/op/go-logging"
 )
 
 // Simple Chaincode implementation
 type SimpleChaincode struct {
 }
 
+// Structured logger for this chaincode, following the convention used by Fabric's asset_management example.
+// The chaincode container's CORE_LOGGING_CHAINCODE / CORE_CHAINCODE_LOGGING_LEVEL settings control the level shown.
+var logger = logging.MustGetLogger("asset_mgmt")
+
 // Main - boilerplate code for entry point
 func main() {
 	err := shim.Start(new(SimpleChaincode))
@@ -26,22 +34,84 @@ func main() {
 	}
 }
 
-// Structure that represents significant attributes of a User, most importantly their Role
+// Structure that represents the PUBLIC attributes of a User, most importantly their Role. AssetBalance is
+// intentionally absent - it lives only in the "collAssetBalances" private data collection, see userBalance.
 type user struct {
 	Id				string	`json:"id"`
 	Role			string	`json:"role"`
+	Frozen			bool	`json:"frozen"`
+}
+
+// Structure that represents the PRIVATE portion of a User's Details, stored only in the collAssetBalances
+// private data collection so that balances are visible solely to authorized organizations.
+type userBalance struct {
 	AssetBalance	int		`json:"assetBalance"`
 }
 
+// Network-wide governance settings controlling how much may move in a single Asset Transfer (MaxTransferQty) and per
+// Sender per UTC day (DailyCap). A value of 0 or less means no limit. Persisted under varPolicy.
+type policy struct {
+	MaxTransferQty	int		`json:"maxTransferQty"`
+	DailyCap		int		`json:"dailyCap"`
+}
+
+// AssetError is returned - never panicked - for expected, User-caused failures (bad arguments, insufficient
+// balance, an unknown function, and the like), so that calling clients can branch on a stable Code instead of
+// string-matching the Message. Error() marshals the struct to JSON so Code and Details survive the round trip
+// through the chaincode shim's plain-string error channel. Panic is reserved for invariants that should never
+// be false in a healthy deployment, e.g. a value already in World State failing to unmarshal.
+type AssetError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *AssetError) Error() string {
+	asBytes, err := json.Marshal(e)
+	if err != nil {		//Should never happen - fall back to the plain Message rather than losing the error entirely
+		return e.Message
+	}
+	return string(asBytes)
+}
+
+// newAssetError builds an AssetError for an expected, User-caused failure. details may be nil.
+func newAssetError(code string, message string, details map[string]interface{}) *AssetError {
+	return &AssetError{Code: code, Message: message, Details: details}
+}
+
+// Error codes returned within AssetError.Code - clients should match on these rather than parsing Message.
+const (
+	errCodeInvalidArgument     = "ERR_INVALID_ARGUMENT"
+	errCodePermissionDenied    = "ERR_PERMISSION_DENIED"
+	errCodeDuplicateUser       = "ERR_DUPLICATE_USER"
+	errCodeNotMember           = "ERR_NOT_MEMBER"
+	errCodeFrozen              = "ERR_FROZEN"
+	errCodePolicyViolation     = "ERR_POLICY_VIOLATION"
+	errCodeInsufficientBalance = "ERR_INSUFFICIENT_BALANCE"
+	errCodeUnknownFunction     = "ERR_UNKNOWN_FUNCTION"
+	errCodeInvalidQuery        = "ERR_INVALID_QUERY"
+	errCodeInternal            = "ERR_INTERNAL"
+)
+
 /* Initialization of global internal constants */
 const varJoinedUsersIndex = "_joinedUsersIndex"		//Key name to be used for list of joined users
 const varJoinedUsers = "_joinedUsers_"
+const varPolicy = "_policy"							//Key name for the network-wide governance policy
+const varTransferHash = "_transferHash_"				//Key prefix under which a public, amount-free proof-of-transfer hash is stored
+// Private data collection holding confidential Asset Balances. Its member orgs and endorsement policy are NOT
+// defined here - they must be supplied at chaincode install/instantiate time via collections_config.json
+// (see that file at the repository root), or every PutPrivateData/GetPrivateData call against this name will fail.
+const collAssetBalances = "collAssetBalances"
 const initialAssetBalance = 100						//Number of asset units to be allocated to a new joinee
 // Labels used to designate valid User Roles within the application:
 const roleAdmin = "admin"		//Administrator - permitted to add new users to the network by invoking "join", read-only access to all accounts
 const roleUser = "user"			//Standard User - permitted to view & transact on own account only
 /* Note: As a convention, system variables stored in the Blockchain begin with an underscore */
 
+// Chaincode event names emitted via stub.SetEvent so off-chain clients can subscribe instead of polling Query
+const eventUserJoined = "user_joined"
+const eventAssetTransferred = "asset_transferred"
+
 // Retrieves the "username" attribute of the chaincode invoker and returns it as a string.
 func (t *SimpleChaincode) getUsernameFromEcert(stub shim.ChaincodeStubInterface) (string, error) {
     userName, err := stub.ReadCertAttribute("username");
@@ -49,9 +119,30 @@ func (t *SimpleChaincode) getUsernameFromEcert(stub shim.ChaincodeStubInterface)
 	return string(userName), nil
 }
 
+// Builds the composite key under which a User's Details record is stored, keyed solely by User ID.
+func (t *SimpleChaincode) userKey(stub shim.ChaincodeStubInterface, userId string) (string, error) {
+	return stub.CreateCompositeKey("user", []string{userId})
+}
+
+// Builds the secondary composite key that indexes User IDs by Role, enabling efficient range queries such as "list all admins".
+// This key is written alongside the primary "user" record but is never itself used to store User Details.
+func (t *SimpleChaincode) userByRoleKey(stub shim.ChaincodeStubInterface, role string, userId string) (string, error) {
+	return stub.CreateCompositeKey("userByRole", []string{role, userId})
+}
+
+// Builds the composite key under which a Sender's cumulative transferred Asset Quantity for a single UTC day is
+// tracked, so "transfer" can enforce the governance policy's DailyCap. date must be formatted "2006-01-02".
+func (t *SimpleChaincode) dailyTransferredKey(stub shim.ChaincodeStubInterface, senderId string, date string) (string, error) {
+	return stub.CreateCompositeKey("dailyTransferred", []string{senderId, date})
+}
+
 // Retrieves User Details for the specified User ID, which must be a previously "join"ed User.
 func (t *SimpleChaincode) getUserDetails(stub shim.ChaincodeStubInterface, userId string) (user, error) {
-	userDetailsAsBytes, err := stub.GetState(varJoinedUsers + userId)
+	userKey, errUserKey := t.userKey(stub, userId)
+	if errUserKey != nil {
+		return user{}, errors.New("ERROR: Failed to build composite key for User ID '" + userId + "'. Source: getUserDetails. Details: " + errUserKey.Error())
+	}
+	userDetailsAsBytes, err := stub.GetState(userKey)
 	if err != nil {
 		return user{}, errors.New("ERROR: Failure while getting User Details for User ID '" + userId + "'. Source: getUserDetails. Details: " + err.Error())
 	}
@@ -63,50 +154,76 @@ func (t *SimpleChaincode) getUserDetails(stub shim.ChaincodeStubInterface, userI
 	return userDetails, nil
 }
 
+// Retrieves the confidential Asset Balance for the specified User ID from the collAssetBalances private data collection.
+// Returns an error if the calling peer is not a member of the collection - callers exposed to end clients (e.g.
+// Query's "getassetbalance") should translate that error into a generic "access denied" rather than surfacing it raw.
+func (t *SimpleChaincode) getAssetBalance(stub shim.ChaincodeStubInterface, userId string) (int, error) {
+	balanceAsBytes, err := stub.GetPrivateData(collAssetBalances, userId)
+	if err != nil {
+		return 0, errors.New("ERROR: Failure while getting Asset Balance for User ID '" + userId + "'. Source: getAssetBalance. Details: " + err.Error())
+	}
+	if balanceAsBytes == nil {		//No balance has been recorded yet for this User
+		return 0, nil
+	}
+	var balanceDetails userBalance
+	errUnmarshalBalance := json.Unmarshal(balanceAsBytes, &balanceDetails)
+	if errUnmarshalBalance != nil {
+		panic("ERROR: Failed to unmarshal Asset Balance. Source: getAssetBalance. Details: " + errUnmarshalBalance.Error())
+	}
+	return balanceDetails.AssetBalance, nil
+}
+
+// Persists the confidential Asset Balance for the specified User ID to the collAssetBalances private data collection.
+func (t *SimpleChaincode) setAssetBalance(stub shim.ChaincodeStubInterface, userId string, balance int) error {
+	balanceAsBytes, err := json.Marshal(userBalance{AssetBalance: balance})
+	if err != nil {
+		return errors.New("ERROR: Failure while marshalling Asset Balance for User ID '" + userId + "'. Source: setAssetBalance. Details: " + err.Error())
+	}
+	errSaveBalance := stub.PutPrivateData(collAssetBalances, userId, balanceAsBytes)
+	if errSaveBalance != nil {
+		return errors.New("ERROR: Failure while storing Asset Balance for User ID '" + userId + "'. Source: setAssetBalance. Details: " + errSaveBalance.Error())
+	}
+	return nil
+}
+
 // Functionality for a new User to join the network. Expects precisely one argument - the unique ID of the joinee
 func (t *SimpleChaincode) join(stub shim.ChaincodeStubInterface, userId string, userRole string, args []string) ([]byte, error) {
 	const errorHeader = "ERROR: Source: join. "
 	if len(args) != 2 {				//Ensure that only the expected number of arguments were passed in
-		panic(errorHeader + "Incorrect number of arguments - expecting 2 (Joinee User ID, Joinee User Role).")
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 2 (Joinee User ID, Joinee User Role).", nil)
 	}
-	
+
 	// Check that the User performing the operation is either the special "admin" User (to support initial joins) or in an "admin" role
 	if userId != "admin" && userRole != roleAdmin {
-		panic(errorHeader + "Permission denied - executing User must be 'admin' or assigned an Administrator User Role.")
+		return nil, newAssetError(errCodePermissionDenied, errorHeader+"Permission denied - executing User must be 'admin' or assigned an Administrator User Role.", nil)
 	}
 
 	// Validate the User ID of the joinee
 	joineeId := args[0]
 	if strings.Index(joineeId, "_") == 0 {		//Do not accept User IDs beginning with an underscore
-		panic(errorHeader + "Source: First input parameter. User ID of new joinee must not begin with an underscore.")
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Source: First input parameter. User ID of new joinee must not begin with an underscore.", nil)
 	}
-	
+
 	joineeRole := args[1]
 	if joineeRole != roleAdmin && joineeRole != roleUser {		//Input Role parameter is invalid
-		panic(errorHeader + "Source: Second input parameter. Invalid Role for new joinee.")
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Source: Second input parameter. Invalid Role for new joinee.", nil)
 	}
 	
-	// Get the Index of Joined Users
-	joinedUsersIndexAsBytes, errGetJoinedUsersIndex := stub.GetState(varJoinedUsersIndex)
-	if errGetJoinedUsersIndex != nil {
-		panic(errorHeader + "Failed to get Index of Joined Users.")
-	}
-	var joinedUsersIndex []string
-	errUnmarshalJoinedUsersIndex := json.Unmarshal(joinedUsersIndexAsBytes, &joinedUsersIndex)
-	if errUnmarshalJoinedUsersIndex != nil {
-		panic(errorHeader + "Failed to unmarshal Index of Joined Users.")
+	joineeKey, errJoineeKey := t.userKey(stub, joineeId)		//Composite key "user"+joineeId - the account's public record, replacing the old linear index entry
+	if errJoineeKey != nil {
+		panic(errorHeader + "Failed to build composite key for Joinee ID '" + joineeId + "'. Details: " + errJoineeKey.Error())
 	}
 
-	// Validate whether joinee User ID is already present in the Index of Joined Users
-	for _, val := range joinedUsersIndex {
-		if val == joineeId {
-			panic(errorHeader + "A User has previously joined with the same User ID.")
-		}
+	// Validate whether joinee User ID is already present, via a direct existence check rather than a scan of a linear index
+	existingJoineeDetailsAsBytes, errGetExistingJoineeDetails := stub.GetState(joineeKey)
+	if errGetExistingJoineeDetails != nil {
+		panic(errorHeader + "Failed to check for an existing User with Joinee ID '" + joineeId + "'. Details: " + errGetExistingJoineeDetails.Error())
+	}
+	if existingJoineeDetailsAsBytes != nil {
+		return nil, newAssetError(errCodeDuplicateUser, errorHeader+"A User has previously joined with the same User ID.", map[string]interface{}{"userId": joineeId})
 	}
 
-	joineeKey := varJoinedUsers + joineeId				//Create the key name for lookup by concatenating the system variable prefix with the Joinee ID
-	joineeDetails := user{Id: joineeId, Role: joineeRole, AssetBalance: initialAssetBalance }			//Create object representing the new joinee
-	// Note: Everyone starts with the number of units defined by the 'initialAssetBalance' constant
+	joineeDetails := user{Id: joineeId, Role: joineeRole}			//Create object representing the new joinee's PUBLIC attributes
 	joineeDetailsAsBytes, errMarshalJoineeDetails := json.Marshal(joineeDetails)
 	if errMarshalJoineeDetails != nil {
 		panic(errorHeader + "Failure while marshalling User Details for Joinee ID '" + joineeId + "'. Details: " + errMarshalJoineeDetails.Error())
@@ -116,20 +233,14 @@ func (t *SimpleChaincode) join(stub shim.ChaincodeStubInterface, userId string,
 		panic(errorHeader + "Failure while storing User Details for Joinee ID '" + joineeId + "'. Details: " + errSaveJoineeDetails.Error())
 	}
 
-	// Add joinee User ID to Index of Joined Users and save updated Index to the Blockchain
-	var newJoinedUsersIndex []string = append(joinedUsersIndex, joineeId)
-	newJoinedUsersIndexAsBytes, errMarshalJoinedUsersIndex := json.Marshal(newJoinedUsersIndex)
-	var errMsg string
-	if errMarshalJoinedUsersIndex != nil {
-		errMsg = errorHeader + "Failure while marshalling Index of Joined Users after adding Joinee ID '" + joineeId + "'. Details: " + errMarshalJoineeDetails.Error()
-	}
-	errSaveJoinedUsersIndex := stub.PutState(varJoinedUsersIndex, newJoinedUsersIndexAsBytes)
-	if errSaveJoinedUsersIndex != nil {
-		errMsg = errorHeader + "Failure while adding Joinee ID '" + joineeId + "'. to the Index of Joined Users. Details: " + errSaveJoinedUsersIndex.Error()
-	}
-	// Roll back addition of User Details if there are any errors while updating the Index of Joined Users
-	if errMarshalJoinedUsersIndex != nil || errSaveJoinedUsersIndex != nil {
-		errMsg = errMsg + "\nRolling back addition of new User Details... "
+	// Maintain the secondary "userByRole" composite key so Users can be range-queried by Role (e.g. list all admins)
+	joineeRoleKey, errJoineeRoleKey := t.userByRoleKey(stub, joineeRole, joineeId)
+	if errJoineeRoleKey != nil {
+		panic(errorHeader + "Failed to build userByRole composite key for Joinee ID '" + joineeId + "'. Details: " + errJoineeRoleKey.Error())
+	}
+	errSaveJoineeRoleIndex := stub.PutState(joineeRoleKey, []byte{0x00})
+	if errSaveJoineeRoleIndex != nil {
+		errMsg := errorHeader + "Failure while indexing Joinee ID '" + joineeId + "' by Role. Details: " + errSaveJoineeRoleIndex.Error() + "\nRolling back addition of new User Details... "
 		errDeleteJoineeDetails := stub.DelState(joineeKey)
 		if errDeleteJoineeDetails == nil {
 			errMsg = errMsg + "rollback succeeded for new Joinee ID '" + joineeId + "'."
@@ -139,6 +250,36 @@ func (t *SimpleChaincode) join(stub shim.ChaincodeStubInterface, userId string,
 		panic(errMsg)
 	}
 
+	// Seed the new joinee's confidential Asset Balance in the private data collection
+	// Note: Everyone starts with the number of units defined by the 'initialAssetBalance' constant
+	errSetJoineeBalance := t.setAssetBalance(stub, joineeId, initialAssetBalance)
+	if errSetJoineeBalance != nil {
+		errMsg := errorHeader + "Failure while seeding Asset Balance for Joinee ID '" + joineeId + "'. Details: " + errSetJoineeBalance.Error() + "\nRolling back addition of new User Details... "
+		errDeleteJoineeRoleIndex := stub.DelState(joineeRoleKey)
+		errDeleteJoineeDetails := stub.DelState(joineeKey)
+		if errDeleteJoineeRoleIndex == nil && errDeleteJoineeDetails == nil {
+			errMsg = errMsg + "rollback succeeded for new Joinee ID '" + joineeId + "'."
+		} else {
+			errMsg = errMsg + "ERROR: ROLLBACK FAILED FOR NEW JOINEE ID '" + joineeId + "'. A SYSTEM ADMINISTRATOR SHOULD IDEALLY PERFORM MANUALLY ROLLBACK BY DELETING STATE FOR THE FOLLOWING KEYS FROM WORLD STATE: " + joineeKey + ", " + joineeRoleKey
+		}
+		panic(errMsg)
+	}
+
+	// Notify subscribed clients that a new User has joined
+	joinEventPayload, errMarshalJoinEvent := json.Marshal(map[string]interface{}{
+		"userId":         joineeId,
+		"role":           joineeRole,
+		"initialBalance": initialAssetBalance,
+		"txId":           stub.GetTxID(),
+	})
+	if errMarshalJoinEvent != nil {
+		panic(errorHeader + "Failure while marshalling '" + eventUserJoined + "' event payload for Joinee ID '" + joineeId + "'. Details: " + errMarshalJoinEvent.Error())
+	}
+	errSetJoinEvent := stub.SetEvent(eventUserJoined, joinEventPayload)
+	if errSetJoinEvent != nil {
+		panic(errorHeader + "Failure while emitting '" + eventUserJoined + "' event for Joinee ID '" + joineeId + "'. Details: " + errSetJoinEvent.Error())
+	}
+
 	return nil, nil
 }
 
@@ -150,7 +291,7 @@ func (t *SimpleChaincode) transfer(stub shim.ChaincodeStubInterface, userId stri
 	const errorHeader = "ERROR: Source: transfer. "
 
 	if len(args) != 3 {		//Ensure that only the expected number of arguments were passed in
-		return nil, errors.New(errorHeader + "Incorrect number of arguments - expecting 3 (Sender ID, Receiver ID, Asset Quantity).")
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 3 (Sender ID, Receiver ID, Asset Quantity).", nil)
 	}
 
 	// The first two arguments are provided by the system implementation of Invoke()
@@ -158,113 +299,453 @@ func (t *SimpleChaincode) transfer(stub shim.ChaincodeStubInterface, userId stri
 	receiverId := args[1]
 	// Validate arguments
 	if strings.Index(senderId, "_") == 0 || strings.Index(receiverId, "_") == 0 {
-		panic(errorHeader + "User IDs of Sender and Receiver must not begin with an underscore.")
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"User IDs of Sender and Receiver must not begin with an underscore.", nil)
 	}
 	if senderId == receiverId {
-		panic(errorHeader + "Sender and Receiver must not be the same User.")
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Sender and Receiver must not be the same User.", nil)
 	}
 	if userId != senderId {			//Disallow the transaction when the User performing the transfer is the Sender
-		panic(errorHeader + "Permission denied - the executing User must match the Sender ID for a successful transfer.")
+		return nil, newAssetError(errCodePermissionDenied, errorHeader+"Permission denied - the executing User must match the Sender ID for a successful transfer.", nil)
 	}
 	assetQuantity, errConvAssetQty := strconv.Atoi(args[2])
-	if errConvAssetQty != nil { panic(errorHeader + "Expecting integer value for quantity of Asset to be trasferred.") }
+	if errConvAssetQty != nil {
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Expecting integer value for quantity of Asset to be trasferred.", nil)
+	}
 	
-	joinedUsersIndexAsBytes, errGetJoinedUsersIndex := stub.GetState(varJoinedUsersIndex)		//Get the Index of Joined Users
-	if errGetJoinedUsersIndex != nil {
-		panic(errorHeader + "Failed to get Index of Joined Users.")
+	senderKey, errSenderKey := t.userKey(stub, senderId)
+	if errSenderKey != nil {
+		panic(errorHeader + "Failed to build composite key for Sender ID '" + senderId + "'. Details: " + errSenderKey.Error())
 	}
-	var joinedUsersIndex []string
-	errUnmarshalJoinedUsersIndex := json.Unmarshal(joinedUsersIndexAsBytes, &joinedUsersIndex)
-	if errUnmarshalJoinedUsersIndex != nil {
-		panic(errorHeader + "Failed to unmarshal Index of Joined Users.")
+	receiverKey, errReceiverKey := t.userKey(stub, receiverId)
+	if errReceiverKey != nil {
+		panic(errorHeader + "Failed to build composite key for Receiver ID '" + receiverId + "'. Details: " + errReceiverKey.Error())
 	}
-	
-	// Check that both Sender and Receiver User IDs are already present in the Index of Joined Users
-	var validSenderId, validReceiverId bool = false, false
-	for _, val := range joinedUsersIndex {
-		if val == senderId {
-			validSenderId = true
-		}
-		if val == receiverId {
-			validReceiverId = true
-		}
+
+	// Check that both Sender and Receiver are already members, via a direct existence check rather than a scan of a linear index
+	senderExistsAsBytes, errGetSenderExists := stub.GetState(senderKey)
+	if errGetSenderExists != nil {
+		panic(errorHeader + "Failed to check membership for Sender ID '" + senderId + "'. Details: " + errGetSenderExists.Error())
 	}
-	if validSenderId == false {
-		panic(errorHeader + "Sender is not a member and will have to join the network before attempting this Asset Transfer.")
+	if senderExistsAsBytes == nil {
+		return nil, newAssetError(errCodeNotMember, errorHeader+"Sender is not a member and will have to join the network before attempting this Asset Transfer.", map[string]interface{}{"senderId": senderId})
 	}
-	if validReceiverId == false {
-		panic(errorHeader + "Receiver is not a member and will have to join the network before attempting this Asset Transfer.")
+	receiverExistsAsBytes, errGetReceiverExists := stub.GetState(receiverKey)
+	if errGetReceiverExists != nil {
+		panic(errorHeader + "Failed to check membership for Receiver ID '" + receiverId + "'. Details: " + errGetReceiverExists.Error())
 	}
-	
-	// Retrieve current Asset Balance for Sender from World State
+	if receiverExistsAsBytes == nil {
+		return nil, newAssetError(errCodeNotMember, errorHeader+"Receiver is not a member and will have to join the network before attempting this Asset Transfer.", map[string]interface{}{"receiverId": receiverId})
+	}
+
+	// Retrieve Public User Details (for the Frozen check) for Sender and Receiver
 	senderDetails, errGetSenderDetails := t.getUserDetails(stub, senderId)
 	if errGetSenderDetails != nil {
 		panic(errorHeader + "Failed to get User Information for Sender '" + senderId +"'. Details: " + errGetSenderDetails.Error())
 	}
-	senderAssetBalance := senderDetails.AssetBalance
-	// Validate whether Sender has sufficient Assets to complete the requested transaction 
-	newSenderAssetBalance := senderAssetBalance - assetQuantity		//Compute new Sender Balance after transferring specified quantity
-	if newSenderAssetBalance < 0 {
-		panic(errorHeader + "Sender does not possess sufficient assets to complete the transaction. senderAssetBalance: " + string(senderDetails.AssetBalance))
-	}
-
-	// Retrieve current Asset Balance for Receiver from World State
 	receiverDetails, errGetReceiverDetails := t.getUserDetails(stub, receiverId)
 	if errGetReceiverDetails != nil {
 		panic(errorHeader + "Failed to get User Information for Receiver '" + receiverId +"'. Details: " + errGetReceiverDetails.Error())
 	}
-	receiverAssetBalance := receiverDetails.AssetBalance
 
-	senderDetails.AssetBalance = newSenderAssetBalance
-	receiverDetails.AssetBalance = receiverAssetBalance + assetQuantity		//Compute new Receiver Balance after transferring specified quantity
+	// Reject the transaction outright if either party has been frozen by an Administrator
+	if senderDetails.Frozen {
+		return nil, newAssetError(errCodeFrozen, errorHeader+"Sender '"+senderId+"' is frozen and may not send assets.", map[string]interface{}{"senderId": senderId})
+	}
+	if receiverDetails.Frozen {
+		return nil, newAssetError(errCodeFrozen, errorHeader+"Receiver '"+receiverId+"' is frozen and may not receive assets.", map[string]interface{}{"receiverId": receiverId})
+	}
 
-	// Update Sender and Receiver Asset Balances in blockchain World State
-	newSenderDetailsAsBytes, errMarshalSenderDetails := json.Marshal(senderDetails)
-	if errMarshalSenderDetails != nil {
-		panic(errorHeader + "Failure while marshalling updated User Details for Sender '" + senderId + "'. Details: " + errMarshalSenderDetails.Error())
+	// Enforce the network-wide governance policy, if one has been set via 'setpolicy'
+	transferPolicy, errGetPolicy := t.getPolicy(stub)
+	if errGetPolicy != nil {
+		panic(errorHeader + "Failed to get governance policy. Details: " + errGetPolicy.Error())
 	}
-	newReceiverDetailsAsBytes, errMarshalReceiverDetails := json.Marshal(receiverDetails)
-	if errMarshalReceiverDetails != nil {
-		panic(errorHeader + "Failure while marshalling updated User Details for Receiver ID '" + receiverId + "'. Details: " + errMarshalReceiverDetails.Error())
+	if transferPolicy.MaxTransferQty > 0 && assetQuantity > transferPolicy.MaxTransferQty {
+		return nil, newAssetError(errCodePolicyViolation, errorHeader+"Asset Quantity '"+strconv.Itoa(assetQuantity)+"' exceeds the maximum permitted per-transfer quantity of '"+strconv.Itoa(transferPolicy.MaxTransferQty)+"'.", map[string]interface{}{"assetQuantity": assetQuantity, "maxTransferQty": transferPolicy.MaxTransferQty})
 	}
-	errSaveSenderDetails := stub.PutState(varJoinedUsers + senderId, newSenderDetailsAsBytes)
-	if errSaveSenderDetails != nil {
-		panic(errorHeader + "Transaction failed - unable to update Asset Balance for Sender ID '" + senderId +"'. Details: " + errSaveSenderDetails.Error())
+
+	// Enforce the Sender's DailyCap, if one has been set via 'setpolicy'. The cumulative quantity already transferred
+	// today is tracked under the "dailyTransferred" composite key, keyed by Sender ID and UTC calendar date.
+	var dailyKey string
+	var dailyTransferredSoFar int
+	if transferPolicy.DailyCap > 0 {
+		txTimestamp, errGetTxTimestamp := stub.GetTxTimestamp()
+		if errGetTxTimestamp != nil {
+			panic(errorHeader + "Failed to get transaction timestamp for daily cap enforcement. Details: " + errGetTxTimestamp.Error())
+		}
+		today := time.Unix(txTimestamp.Seconds, 0).UTC().Format("2006-01-02")
+		var errDailyKey error
+		dailyKey, errDailyKey = t.dailyTransferredKey(stub, senderId, today)
+		if errDailyKey != nil {
+			panic(errorHeader + "Failed to build daily-cap composite key for Sender ID '" + senderId + "'. Details: " + errDailyKey.Error())
+		}
+		dailyTransferredAsBytes, errGetDailyTransferred := stub.GetState(dailyKey)
+		if errGetDailyTransferred != nil {
+			panic(errorHeader + "Failed to get cumulative daily transferred quantity for Sender ID '" + senderId + "'. Details: " + errGetDailyTransferred.Error())
+		}
+		if dailyTransferredAsBytes != nil {
+			var errConvDailyTransferred error
+			dailyTransferredSoFar, errConvDailyTransferred = strconv.Atoi(string(dailyTransferredAsBytes))
+			if errConvDailyTransferred != nil {
+				panic(errorHeader + "Failed to parse cumulative daily transferred quantity for Sender ID '" + senderId + "'. Details: " + errConvDailyTransferred.Error())
+			}
+		}
+		if dailyTransferredSoFar+assetQuantity > transferPolicy.DailyCap {
+			return nil, newAssetError(errCodePolicyViolation, errorHeader+"Asset Quantity '"+strconv.Itoa(assetQuantity)+"' would push Sender's total for '"+today+"' over the daily cap of '"+strconv.Itoa(transferPolicy.DailyCap)+"'.", map[string]interface{}{"assetQuantity": assetQuantity, "dailyCap": transferPolicy.DailyCap, "alreadyTransferredToday": dailyTransferredSoFar})
+		}
+	}
+
+	// Retrieve current confidential Asset Balances for Sender and Receiver from the collAssetBalances private data collection
+	senderAssetBalance, errGetSenderBalance := t.getAssetBalance(stub, senderId)
+	if errGetSenderBalance != nil {
+		panic(errorHeader + "Failed to get Asset Balance for Sender '" + senderId + "'. Details: " + errGetSenderBalance.Error())
 	}
-	errSaveRecieverDetails := stub.PutState(varJoinedUsers + receiverId, newReceiverDetailsAsBytes)
-	if errSaveRecieverDetails != nil {
-		errMsg := errorHeader + "Transaction failed - unable to update Asset Balance for Receiver ID '" + receiverId +"'. Details: " + errSaveRecieverDetails.Error() + "\nAttempting to roll back deduction from Sender account... "
-		senderDetails.AssetBalance = senderAssetBalance
-		senderDetailsAsBytes, _ := json.Marshal(senderDetails)
-		errRollback := stub.PutState(senderId, senderDetailsAsBytes)		//Rollback deduction from sender account
+	// Validate whether Sender has sufficient Assets to complete the requested transaction
+	newSenderAssetBalance := senderAssetBalance - assetQuantity		//Compute new Sender Balance after transferring specified quantity
+	if newSenderAssetBalance < 0 {
+		return nil, newAssetError(errCodeInsufficientBalance, errorHeader+"Sender does not possess sufficient assets to complete the transaction. senderAssetBalance: "+strconv.Itoa(senderAssetBalance), map[string]interface{}{"senderAssetBalance": senderAssetBalance, "assetQuantity": assetQuantity})
+	}
+	receiverAssetBalance, errGetReceiverBalance := t.getAssetBalance(stub, receiverId)
+	if errGetReceiverBalance != nil {
+		panic(errorHeader + "Failed to get Asset Balance for Receiver '" + receiverId + "'. Details: " + errGetReceiverBalance.Error())
+	}
+	newReceiverAssetBalance := receiverAssetBalance + assetQuantity		//Compute new Receiver Balance after transferring specified quantity
+
+	// Update Sender and Receiver Asset Balances in the collAssetBalances private data collection
+	errSetSenderBalance := t.setAssetBalance(stub, senderId, newSenderAssetBalance)
+	if errSetSenderBalance != nil {
+		panic(errorHeader + "Transaction failed - unable to update Asset Balance for Sender ID '" + senderId +"'. Details: " + errSetSenderBalance.Error())
+	}
+	errSetReceiverBalance := t.setAssetBalance(stub, receiverId, newReceiverAssetBalance)
+	if errSetReceiverBalance != nil {
+		errMsg := errorHeader + "Transaction failed - unable to update Asset Balance for Receiver ID '" + receiverId +"'. Details: " + errSetReceiverBalance.Error() + "\nAttempting to roll back deduction from Sender account... "
+		errRollback := t.setAssetBalance(stub, senderId, senderAssetBalance)		//Rollback deduction from sender account
 		if errRollback == nil {		//Rollback successful
-			panic(errMsg + "successfully rolled back asset deduction of '" + string(assetQuantity) + "' from Sender ID '" + senderId +"'.")
+			panic(errMsg + "successfully rolled back asset deduction of '" + strconv.Itoa(assetQuantity) + "' from Sender ID '" + senderId +"'.")
 		} else {					//Rollback failed
-			panic(errMsg + "CRITICAL ERROR: UNABLE TO ROLL BACK ASSET DEDUCTION OF '" + string(assetQuantity) + "' FROM SENDER ID '" + senderId +"'. WORLD STATE IS INCONSISTENT - TRANSACTION MUST BE MANUALLY REVERSED BY AN ADMINISTRATOR! Correct value: " + strconv.Itoa(senderAssetBalance) + "\nError details: " + errRollback.Error())
+			panic(errMsg + "CRITICAL ERROR: UNABLE TO ROLL BACK ASSET DEDUCTION OF '" + strconv.Itoa(assetQuantity) + "' FROM SENDER ID '" + senderId +"'. WORLD STATE IS INCONSISTENT - TRANSACTION MUST BE MANUALLY REVERSED BY AN ADMINISTRATOR! Correct value: " + strconv.Itoa(senderAssetBalance) + "\nError details: " + errRollback.Error())
 		}
 	}
-	
-	fmt.Println("Asset Transfer successful!")
-	fmt.Println("New balances: Sender - " + string(senderDetails.AssetBalance) + "; Receiver - " + string(receiverDetails.AssetBalance))
+
+	// Record the Sender's updated cumulative total for today, now that the balance updates above have succeeded
+	if dailyKey != "" {
+		errSaveDailyTransferred := stub.PutState(dailyKey, []byte(strconv.Itoa(dailyTransferredSoFar+assetQuantity)))
+		if errSaveDailyTransferred != nil {
+			panic(errorHeader + "Failure while updating cumulative daily transferred quantity for Sender ID '" + senderId + "'. Details: " + errSaveDailyTransferred.Error())
+		}
+	}
+
+	// Compute and publish a SHA-256 hash of the transfer payload so non-authorized peers (outside collAssetBalances)
+	// can still verify that the transaction occurred, without being able to see the amounts involved
+	transferPayloadForHash, errMarshalPayloadForHash := json.Marshal(map[string]interface{}{
+		"senderId":   senderId,
+		"receiverId": receiverId,
+		"quantity":   assetQuantity,
+		"txId":       stub.GetTxID(),
+	})
+	if errMarshalPayloadForHash != nil {
+		panic(errorHeader + "Failure while marshalling transfer payload for hashing. Details: " + errMarshalPayloadForHash.Error())
+	}
+	transferHash := sha256.Sum256(transferPayloadForHash)
+	errSaveTransferHash := stub.PutState(varTransferHash + stub.GetTxID(), []byte(hex.EncodeToString(transferHash[:])))
+	if errSaveTransferHash != nil {
+		panic(errorHeader + "Failure while storing transfer proof hash. Details: " + errSaveTransferHash.Error())
+	}
+
+	// Notify subscribed clients that an Asset Transfer has completed; not reached on the rollback path above
+	transferEventPayload, errMarshalTransferEvent := json.Marshal(map[string]interface{}{
+		"senderId":          senderId,
+		"receiverId":        receiverId,
+		"quantity":          assetQuantity,
+		"senderNewBalance":  newSenderAssetBalance,
+		"receiverNewBalance": newReceiverAssetBalance,
+		"txId":              stub.GetTxID(),
+	})
+	if errMarshalTransferEvent != nil {
+		panic(errorHeader + "Failure while marshalling '" + eventAssetTransferred + "' event payload for Sender ID '" + senderId + "'. Details: " + errMarshalTransferEvent.Error())
+	}
+	errSetTransferEvent := stub.SetEvent(eventAssetTransferred, transferEventPayload)
+	if errSetTransferEvent != nil {
+		panic(errorHeader + "Failure while emitting '" + eventAssetTransferred + "' event for Sender ID '" + senderId + "'. Details: " + errSetTransferEvent.Error())
+	}
+
+	logger.Info("Asset Transfer successful! New balances: Sender - " + strconv.Itoa(newSenderAssetBalance) + "; Receiver - " + strconv.Itoa(newReceiverAssetBalance))
+	return nil, nil
+}
+
+// Retrieves the current governance policy from World State. Returns a zero-value policy (no limits enforced) if none has been set yet.
+func (t *SimpleChaincode) getPolicy(stub shim.ChaincodeStubInterface) (policy, error) {
+	policyAsBytes, err := stub.GetState(varPolicy)
+	if err != nil {
+		return policy{}, errors.New("ERROR: Failure while getting governance policy. Source: getPolicy. Details: " + err.Error())
+	}
+	if policyAsBytes == nil {		//No policy has been configured yet - default to unrestricted
+		return policy{}, nil
+	}
+	var currentPolicy policy
+	errUnmarshalPolicy := json.Unmarshal(policyAsBytes, &currentPolicy)
+	if errUnmarshalPolicy != nil {
+		panic("ERROR: Failed to unmarshal governance policy. Source: getPolicy. Details: " + errUnmarshalPolicy.Error())
+	}
+	return currentPolicy, nil
+}
+
+// Admin-only: credits the target User's account with the specified quantity of assets.
+// Argument 1: User ID to credit, Argument 2: Quantity to mint
+func (t *SimpleChaincode) mint(stub shim.ChaincodeStubInterface, userRole string, args []string) ([]byte, error) {
+	const errorHeader = "ERROR: Source: mint. "
+	if userRole != roleAdmin {
+		return nil, newAssetError(errCodePermissionDenied, errorHeader+"Permission denied - executing User must be assigned an Administrator User Role.", nil)
+	}
+	if len(args) != 2 {				//Ensure that only the expected number of arguments were passed in
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 2 (User ID, Quantity).", nil)
+	}
+
+	targetId := args[0]
+	qty, errConvQty := strconv.Atoi(args[1])
+	if errConvQty != nil {
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Expecting integer value for Quantity to be minted.", nil)
+	}
+
+	targetKey, errTargetKey := t.userKey(stub, targetId)		//Confirm the target is a joined User, via a direct existence check
+	if errTargetKey != nil {
+		panic(errorHeader + "Failed to build composite key for User ID '" + targetId + "'. Details: " + errTargetKey.Error())
+	}
+	targetExistsAsBytes, errGetTargetExists := stub.GetState(targetKey)
+	if errGetTargetExists != nil {
+		panic(errorHeader + "Failed to check membership for User ID '" + targetId + "'. Details: " + errGetTargetExists.Error())
+	}
+	if targetExistsAsBytes == nil {
+		return nil, newAssetError(errCodeNotMember, errorHeader+"User ID '"+targetId+"' is not a member of the network.", map[string]interface{}{"userId": targetId})
+	}
+	currentBalance, errGetTargetBalance := t.getAssetBalance(stub, targetId)
+	if errGetTargetBalance != nil {
+		panic(errorHeader + "Failed to get Asset Balance for User ID '" + targetId + "'. Details: " + errGetTargetBalance.Error())
+	}
+
+	errSetTargetBalance := t.setAssetBalance(stub, targetId, currentBalance + qty)
+	if errSetTargetBalance != nil {
+		panic(errorHeader + "Failure while crediting Asset Balance for User ID '" + targetId + "'. Details: " + errSetTargetBalance.Error())
+	}
+
+	logger.Info("mint successful - credited '" + strconv.Itoa(qty) + "' units to User ID '" + targetId + "'.")
+	return nil, nil
+}
+
+// Admin-only: debits the target User's account by the specified quantity of assets. Rejects the request if the balance would go negative.
+// Argument 1: User ID to debit, Argument 2: Quantity to burn
+func (t *SimpleChaincode) burn(stub shim.ChaincodeStubInterface, userRole string, args []string) ([]byte, error) {
+	const errorHeader = "ERROR: Source: burn. "
+	if userRole != roleAdmin {
+		return nil, newAssetError(errCodePermissionDenied, errorHeader+"Permission denied - executing User must be assigned an Administrator User Role.", nil)
+	}
+	if len(args) != 2 {				//Ensure that only the expected number of arguments were passed in
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 2 (User ID, Quantity).", nil)
+	}
+
+	targetId := args[0]
+	qty, errConvQty := strconv.Atoi(args[1])
+	if errConvQty != nil {
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Expecting integer value for Quantity to be burned.", nil)
+	}
+
+	targetKey, errTargetKey := t.userKey(stub, targetId)		//Confirm the target is a joined User, via a direct existence check
+	if errTargetKey != nil {
+		panic(errorHeader + "Failed to build composite key for User ID '" + targetId + "'. Details: " + errTargetKey.Error())
+	}
+	targetExistsAsBytes, errGetTargetExists := stub.GetState(targetKey)
+	if errGetTargetExists != nil {
+		panic(errorHeader + "Failed to check membership for User ID '" + targetId + "'. Details: " + errGetTargetExists.Error())
+	}
+	if targetExistsAsBytes == nil {
+		return nil, newAssetError(errCodeNotMember, errorHeader+"User ID '"+targetId+"' is not a member of the network.", map[string]interface{}{"userId": targetId})
+	}
+	currentBalance, errGetTargetBalance := t.getAssetBalance(stub, targetId)
+	if errGetTargetBalance != nil {
+		panic(errorHeader + "Failed to get Asset Balance for User ID '" + targetId + "'. Details: " + errGetTargetBalance.Error())
+	}
+	newBalance := currentBalance - qty
+	if newBalance < 0 {
+		return nil, newAssetError(errCodeInsufficientBalance, errorHeader+"User ID '"+targetId+"' does not possess sufficient assets to burn the requested quantity. assetBalance: "+strconv.Itoa(currentBalance), map[string]interface{}{"assetBalance": currentBalance, "quantity": qty})
+	}
+
+	errSetTargetBalance := t.setAssetBalance(stub, targetId, newBalance)
+	if errSetTargetBalance != nil {
+		panic(errorHeader + "Failure while debiting Asset Balance for User ID '" + targetId + "'. Details: " + errSetTargetBalance.Error())
+	}
+
+	logger.Info("burn successful - debited '" + strconv.Itoa(qty) + "' units from User ID '" + targetId + "'.")
+	return nil, nil
+}
+
+// Admin-only: sets the Frozen flag for the target User to frozen, blocking or re-enabling their participation in "transfer".
+// Argument 1: User ID. The desired Frozen state is not an argument: Invoke passes true for 'freeze' and false for 'unfreeze'.
+func (t *SimpleChaincode) setFrozenState(stub shim.ChaincodeStubInterface, userRole string, args []string, frozen bool, errorHeader string) ([]byte, error) {
+	if userRole != roleAdmin {
+		return nil, newAssetError(errCodePermissionDenied, errorHeader+"Permission denied - executing User must be assigned an Administrator User Role.", nil)
+	}
+	if len(args) != 1 {				//Ensure that only the expected number of arguments were passed in
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 1 (User ID).", nil)
+	}
+
+	targetId := args[0]
+	targetKey, errTargetKey := t.userKey(stub, targetId)
+	if errTargetKey != nil {
+		panic(errorHeader + "Failed to build composite key for User ID '" + targetId + "'. Details: " + errTargetKey.Error())
+	}
+	targetExistsAsBytes, errGetTargetExists := stub.GetState(targetKey)		//Confirm the target is a joined User, via a direct existence check
+	if errGetTargetExists != nil {
+		panic(errorHeader + "Failed to check membership for User ID '" + targetId + "'. Details: " + errGetTargetExists.Error())
+	}
+	if targetExistsAsBytes == nil {
+		return nil, newAssetError(errCodeNotMember, errorHeader+"User ID '"+targetId+"' is not a member of the network.", map[string]interface{}{"userId": targetId})
+	}
+	targetDetails, errGetTargetDetails := t.getUserDetails(stub, targetId)
+	if errGetTargetDetails != nil {
+		panic(errorHeader + "Failed to get User Information for User ID '" + targetId + "'. Details: " + errGetTargetDetails.Error())
+	}
+	targetDetails.Frozen = frozen
+
+	targetDetailsAsBytes, errMarshalTargetDetails := json.Marshal(targetDetails)
+	if errMarshalTargetDetails != nil {
+		panic(errorHeader + "Failure while marshalling updated User Details for User ID '" + targetId + "'. Details: " + errMarshalTargetDetails.Error())
+	}
+	errSaveTargetDetails := stub.PutState(targetKey, targetDetailsAsBytes)
+	if errSaveTargetDetails != nil {
+		panic(errorHeader + "Failure while updating Frozen state for User ID '" + targetId + "'. Details: " + errSaveTargetDetails.Error())
+	}
+
+	logger.Info("Frozen state for User ID '" + targetId + "' set to '" + strconv.FormatBool(frozen) + "'.")
+	return nil, nil
+}
+
+// Admin-only: persists the network-wide governance policy enforced by "transfer".
+// Argument 1: Maximum Transfer Quantity, Argument 2: Daily Cap
+func (t *SimpleChaincode) setPolicy(stub shim.ChaincodeStubInterface, userRole string, args []string) ([]byte, error) {
+	const errorHeader = "ERROR: Source: setpolicy. "
+	if userRole != roleAdmin {
+		return nil, newAssetError(errCodePermissionDenied, errorHeader+"Permission denied - executing User must be assigned an Administrator User Role.", nil)
+	}
+	if len(args) != 2 {				//Ensure that only the expected number of arguments were passed in
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 2 (Max Transfer Quantity, Daily Cap).", nil)
+	}
+
+	maxTransferQty, errConvMaxTransferQty := strconv.Atoi(args[0])
+	if errConvMaxTransferQty != nil {
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Expecting integer value for Max Transfer Quantity.", nil)
+	}
+	dailyCap, errConvDailyCap := strconv.Atoi(args[1])
+	if errConvDailyCap != nil {
+		return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Expecting integer value for Daily Cap.", nil)
+	}
+
+	newPolicy := policy{MaxTransferQty: maxTransferQty, DailyCap: dailyCap}
+	newPolicyAsBytes, errMarshalPolicy := json.Marshal(newPolicy)
+	if errMarshalPolicy != nil {
+		panic(errorHeader + "Failure while marshalling new governance policy. Details: " + errMarshalPolicy.Error())
+	}
+	errSavePolicy := stub.PutState(varPolicy, newPolicyAsBytes)
+	if errSavePolicy != nil {
+		panic(errorHeader + "Failure while persisting new governance policy. Details: " + errSavePolicy.Error())
+	}
+
+	logger.Info("setpolicy successful - maxTransferQty: " + strconv.Itoa(maxTransferQty) + "; dailyCap: " + strconv.Itoa(dailyCap))
+	return nil, nil
+}
+
+// One-time maintenance operation: migrates User Details still stored under the legacy "_joinedUsers_<id>" keys and
+// the "_joinedUsersIndex" it depended on onto the "user" and "userByRole" composite keys, so an existing deployment
+// can adopt the new layout in place. A no-op if no legacy Index of Joined Users is present.
+func (t *SimpleChaincode) migrateToCompositeKeys(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	const errorHeader = "ERROR: Source: migrateToCompositeKeys. "
+
+	joinedUsersIndexAsBytes, errGetJoinedUsersIndex := stub.GetState(varJoinedUsersIndex)
+	if errGetJoinedUsersIndex != nil {
+		return nil, errors.New(errorHeader + "Failed to get legacy Index of Joined Users. Details: " + errGetJoinedUsersIndex.Error())
+	}
+	if joinedUsersIndexAsBytes == nil {		//Nothing to migrate
+		return nil, nil
+	}
+	var joinedUsersIndex []string
+	errUnmarshalJoinedUsersIndex := json.Unmarshal(joinedUsersIndexAsBytes, &joinedUsersIndex)
+	if errUnmarshalJoinedUsersIndex != nil {
+		return nil, errors.New(errorHeader + "Failed to unmarshal legacy Index of Joined Users. Details: " + errUnmarshalJoinedUsersIndex.Error())
+	}
+
+	migrated := 0
+	for _, legacyUserId := range joinedUsersIndex {
+		legacyUserDetailsAsBytes, errGetLegacyUserDetails := stub.GetState(varJoinedUsers + legacyUserId)
+		if errGetLegacyUserDetails != nil {
+			return nil, errors.New(errorHeader + "Failed to get legacy User Details for User ID '" + legacyUserId + "'. Details: " + errGetLegacyUserDetails.Error())
+		}
+		if legacyUserDetailsAsBytes == nil {		//Already migrated or never written - skip
+			continue
+		}
+		// The legacy blob predates the public/private split and carries both the public attributes and the Asset Balance together
+		var legacyUserDetails struct {
+			Id				string	`json:"id"`
+			Role			string	`json:"role"`
+			AssetBalance	int		`json:"assetBalance"`
+			Frozen			bool	`json:"frozen"`
+		}
+		errUnmarshalLegacyUserDetails := json.Unmarshal(legacyUserDetailsAsBytes, &legacyUserDetails)
+		if errUnmarshalLegacyUserDetails != nil {
+			return nil, errors.New(errorHeader + "Failed to unmarshal legacy User Details for User ID '" + legacyUserId + "'. Details: " + errUnmarshalLegacyUserDetails.Error())
+		}
+
+		userKey, errUserKey := t.userKey(stub, legacyUserId)
+		if errUserKey != nil {
+			return nil, errors.New(errorHeader + "Failed to build composite key for User ID '" + legacyUserId + "'. Details: " + errUserKey.Error())
+		}
+		publicDetailsAsBytes, errMarshalPublicDetails := json.Marshal(user{Id: legacyUserDetails.Id, Role: legacyUserDetails.Role, Frozen: legacyUserDetails.Frozen})
+		if errMarshalPublicDetails != nil {
+			return nil, errors.New(errorHeader + "Failed to marshal public User Details for User ID '" + legacyUserId + "'. Details: " + errMarshalPublicDetails.Error())
+		}
+		errSaveUser := stub.PutState(userKey, publicDetailsAsBytes)
+		if errSaveUser != nil {
+			return nil, errors.New(errorHeader + "Failed to write composite key record for User ID '" + legacyUserId + "'. Details: " + errSaveUser.Error())
+		}
+
+		errSetBalance := t.setAssetBalance(stub, legacyUserId, legacyUserDetails.AssetBalance)
+		if errSetBalance != nil {
+			return nil, errors.New(errorHeader + "Failed to write private Asset Balance for User ID '" + legacyUserId + "'. Details: " + errSetBalance.Error())
+		}
+
+		roleKey, errRoleKey := t.userByRoleKey(stub, legacyUserDetails.Role, legacyUserId)
+		if errRoleKey != nil {
+			return nil, errors.New(errorHeader + "Failed to build userByRole composite key for User ID '" + legacyUserId + "'. Details: " + errRoleKey.Error())
+		}
+		errSaveRoleIndex := stub.PutState(roleKey, []byte{0x00})
+		if errSaveRoleIndex != nil {
+			return nil, errors.New(errorHeader + "Failed to write userByRole composite key for User ID '" + legacyUserId + "'. Details: " + errSaveRoleIndex.Error())
+		}
+
+		errDeleteLegacyUser := stub.DelState(varJoinedUsers + legacyUserId)
+		if errDeleteLegacyUser != nil {
+			return nil, errors.New(errorHeader + "Failed to delete legacy User Details for User ID '" + legacyUserId + "'. Details: " + errDeleteLegacyUser.Error())
+		}
+		migrated++
+	}
+
+	errDeleteLegacyIndex := stub.DelState(varJoinedUsersIndex)
+	if errDeleteLegacyIndex != nil {
+		return nil, errors.New(errorHeader + "Failed to delete legacy Index of Joined Users. Details: " + errDeleteLegacyIndex.Error())
+	}
+
+	logger.Info("migrateToCompositeKeys migrated " + strconv.Itoa(migrated) + " User(s) to composite keys.")
 	return nil, nil
 }
 
 // Initialize World State
 func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	if len(args) != 0 {		//Ensure expected usage of 'Init' without any arguments
-		return nil, errors.New("Incorrect number of arguments for Init invocation - expecting none.")
+	if len(args) == 1 && args[0] == "migrate" {		//One-time maintenance operation - migrate legacy records onto composite keys
+		return t.migrateToCompositeKeys(stub)
 	}
-	
-	// Initialize Index of Joined Users
-	var empty []string
-	emptyAsBytes, err := json.Marshal(empty)
-	if err != nil {
-		return nil, errors.New("Error initializing new Joined User Index. Cannot continue. Details: " + err.Error())
+	if len(args) != 0 {		//Ensure expected usage of 'Init' without any arguments
+		return nil, newAssetError(errCodeInvalidArgument, "ERROR: Source: Init. Incorrect number of arguments - expecting none.", nil)
 	}
 
-	err = stub.PutState(varJoinedUsersIndex, emptyAsBytes) 	//Start with no active users
-	if err != nil { return nil, err }
-	
+	// User records now live under composite keys created on demand by "join"; no index needs to be seeded here.
 	return nil, nil
 }
 
@@ -272,13 +753,19 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string
 func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) (retBytes []byte, retErr error) {
 	const errorHeader = "ERROR: Source: Invoke. "
 
-	defer func() {		//Handle Fatal Errors by translating a call to panic into a returned error 
-		retBytes = nil
-        fatalErrorMsg := recover().(string)
-		retErr = errors.New(errorHeader + "Details: " + fatalErrorMsg)
-    }()
-	
-	fmt.Println("Invoke() is running function '" + function + "'...")
+	defer func() {		//Translate a panic - reserved for truly unrecoverable invariant violations - into a returned error
+		if r := recover(); r != nil {
+			retBytes = nil
+			fatalErrorMsg, ok := r.(string)
+			if !ok {
+				fatalErrorMsg = fmt.Sprintf("%v", r)
+			}
+			logger.Error(errorHeader + "Recovered from panic. Details: " + fatalErrorMsg)
+			retErr = newAssetError(errCodeInternal, errorHeader+"An internal error occurred.", map[string]interface{}{"details": fatalErrorMsg})
+		}
+	}()
+
+	logger.Debug("Invoke() is running function '" + function + "'...")
 
 	// Get ID and Details for invoking User
 	userId, _ := t.getUsernameFromEcert(stub)
@@ -294,54 +781,193 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function stri
 		return t.join(stub, userId, userDetails.Role, args)
 	} else if function == "transfer" {		//Used for Asset Transfers
 		return t.transfer(stub, userId, userDetails.Role, args)
+	} else if function == "mint" {			//Admin-only: credit a User's Asset Balance
+		return t.mint(stub, userDetails.Role, args)
+	} else if function == "burn" {			//Admin-only: debit a User's Asset Balance
+		return t.burn(stub, userDetails.Role, args)
+	} else if function == "freeze" {			//Admin-only: block a User from participating in "transfer"
+		return t.setFrozenState(stub, userDetails.Role, args, true, "ERROR: Source: freeze. ")
+	} else if function == "unfreeze" {		//Admin-only: re-enable a previously frozen User
+		return t.setFrozenState(stub, userDetails.Role, args, false, "ERROR: Source: unfreeze. ")
+	} else if function == "setpolicy" {		//Admin-only: configure network-wide transfer governance
+		return t.setPolicy(stub, userDetails.Role, args)
 	}
 
-	fmt.Println("Invoke() did not find function: " + function)					//Log error message
-	return nil, errors.New(errorHeader + "Invoke() called with unknown function name: " + function)
+	logger.Warning(errorHeader + "Invoke() did not find function: " + function)
+	return nil, newAssetError(errCodeUnknownFunction, errorHeader+"Invoke() called with unknown function name: "+function, map[string]interface{}{"function": function})
 }
 
 // Query is our entry point for read operations
 func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	fmt.Println("Query() is running function '" + function + "'")
-	
+	logger.Debug("Query() is running function '" + function + "'")
+
 	// Handle different functions
 	if function == "getassetbalance" {
 		if len(args) != 1 {				//Validate the number of arguments
-			return nil, errors.New("Incorrect number of arguments - expecting 1 (User ID).")
+			return nil, newAssetError(errCodeInvalidArgument, "ERROR: Source: Query - getassetbalance. Incorrect number of arguments - expecting 1 (User ID).", nil)
 		}
-		userDetails, errGetUserDetails := t.getUserDetails(stub, args[0])	//Get Asset Balance for specified user
-		if errGetUserDetails != nil {
-			return nil, errors.New("Failed to get Asset Balance for User ID '" + args[0] + "'. Details: " + errGetUserDetails.Error())
+		// Asset Balance lives in the collAssetBalances private data collection; a peer outside that collection
+		// cannot read it, so that failure is surfaced as a generic "access denied" rather than the raw error.
+		assetBalance, errGetAssetBalance := t.getAssetBalance(stub, args[0])
+		if errGetAssetBalance != nil {
+			logger.Warning("getassetbalance: access denied for User ID '" + args[0] + "'. Details: " + errGetAssetBalance.Error())
+			return nil, newAssetError(errCodePermissionDenied, "access denied", nil)
 		}
-		userAssetBalanceAsString := strconv.Itoa(userDetails.AssetBalance)
-		fmt.Println("Retrieved Asset Balance: " + userAssetBalanceAsString)
+		userAssetBalanceAsString := strconv.Itoa(assetBalance)
+		logger.Debug("Retrieved Asset Balance: " + userAssetBalanceAsString)
 		return []byte(userAssetBalanceAsString), nil;
-	} else if function == "getalljoinedusers" {			//Report of all currently joined users and their Asset Balances
+	} else if function == "getalljoinedusers" {			//Report of all currently joined users' IDs (unchanged response shape - a JSON array of User ID strings)
 		const errorHeader = "ERROR: Source: Query - getalljoinedusers. "
-		// Get the Index of Joined Users
-		joinedUsersIndexAsBytes, errGetJoinedUsersIndex := stub.GetState(varJoinedUsersIndex)
-		if errGetJoinedUsersIndex != nil {
-			panic(errorHeader + "Failed to get Index of Joined Users.")
+		// Range over every "user" composite key rather than scanning a monolithic index
+		resultsIterator, errGetUsers := stub.GetStateByPartialCompositeKey("user", []string{})
+		if errGetUsers != nil {
+			panic(errorHeader + "Failed to range over joined Users.")
 		}
-		var joinedUsersIndex []string
-		errUnmarshalJoinedUsersIndex := json.Unmarshal(joinedUsersIndexAsBytes, &joinedUsersIndex)
-		if errUnmarshalJoinedUsersIndex != nil {
-			panic(errorHeader + "Failed to unmarshal Index of Joined Users.")
+		defer resultsIterator.Close()
+
+		var joinedUserIds []string
+		for resultsIterator.HasNext() {
+			kv, errNext := resultsIterator.Next()
+			if errNext != nil {
+				panic(errorHeader + "Failed to iterate over joined Users. Details: " + errNext.Error())
+			}
+			_, keyParts, errSplitKey := stub.SplitCompositeKey(kv.Key)
+			if errSplitKey != nil {
+				panic(errorHeader + "Failed to split user composite key. Details: " + errSplitKey.Error())
+			}
+			joinedUserIds = append(joinedUserIds, keyParts[0])
 		}
 
-		fmt.Println("List of Joined Users:")
-		if len(joinedUsersIndex) == 0 {
-			fmt.Println("No Joined Users found!")
+		logger.Debug("List of Joined Users:")
+		if len(joinedUserIds) == 0 {
+			logger.Debug("No Joined Users found!")
 		} else {
-			// Validate whether joinee User ID is already present in the Index of Joined Users
-			for _, valAsBytes := range joinedUsersIndex {
-				fmt.Println("User: " + string(valAsBytes))
+			for _, joinedUserId := range joinedUserIds {
+				logger.Debug("User: " + joinedUserId)
 			}
 		}
-		fmt.Println("- X -")
-		return joinedUsersIndexAsBytes, nil
+
+		joinedUserIdsAsBytes, errMarshalJoinedUserIds := json.Marshal(joinedUserIds)
+		if errMarshalJoinedUserIds != nil {
+			panic(errorHeader + "Failed to marshal joined User IDs. Details: " + errMarshalJoinedUserIds.Error())
+		}
+		return joinedUserIdsAsBytes, nil
+	} else if function == "getusersbyrole" {			//Range query over the "userByRole" secondary composite key, e.g. list all admins
+		const errorHeader = "ERROR: Source: Query - getusersbyrole. "
+		if len(args) != 1 {				//Validate the number of arguments
+			return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 1 (Role).", nil)
+		}
+		role := args[0]
+		roleIndexIterator, errGetRoleIndex := stub.GetStateByPartialCompositeKey("userByRole", []string{role})
+		if errGetRoleIndex != nil {
+			panic(errorHeader + "Failed to range over Users with Role '" + role + "'. Details: " + errGetRoleIndex.Error())
+		}
+		defer roleIndexIterator.Close()
+
+		var usersWithRole []user
+		for roleIndexIterator.HasNext() {
+			kv, errNext := roleIndexIterator.Next()
+			if errNext != nil {
+				panic(errorHeader + "Failed to iterate over Users with Role '" + role + "'. Details: " + errNext.Error())
+			}
+			_, keyParts, errSplitKey := stub.SplitCompositeKey(kv.Key)
+			if errSplitKey != nil {
+				panic(errorHeader + "Failed to split userByRole composite key. Details: " + errSplitKey.Error())
+			}
+			matchedUserId := keyParts[1]
+			matchedUser, errGetMatchedUser := t.getUserDetails(stub, matchedUserId)
+			if errGetMatchedUser != nil {
+				panic(errorHeader + "Failed to get User Information for User ID '" + matchedUserId + "'. Details: " + errGetMatchedUser.Error())
+			}
+			usersWithRole = append(usersWithRole, matchedUser)
+		}
+
+		usersWithRoleAsBytes, errMarshalUsersWithRole := json.Marshal(usersWithRole)
+		if errMarshalUsersWithRole != nil {
+			panic(errorHeader + "Failed to marshal Users with Role '" + role + "'. Details: " + errMarshalUsersWithRole.Error())
+		}
+		logger.Debug("getusersbyrole matched " + strconv.Itoa(len(usersWithRole)) + " User(s) with Role '" + role + "'.")
+		return usersWithRoleAsBytes, nil
+	} else if function == "queryassets" {			//Rich ad-hoc query over joined Users' PUBLIC attributes (id, role, frozen) via a CouchDB Mango selector.
+		// Asset Balance is confidential and lives only in the collAssetBalances private data collection (see getAssetBalance) -
+		// it is not part of the public world-state document indexed here, so a selector filtering on "assetBalance" will never match.
+		const errorHeader = "ERROR: Source: Query - queryassets. "
+		if len(args) != 1 {				//Validate the number of arguments
+			return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 1 (JSON Selector).", nil)
+		}
+		resultsIterator, errGetQueryResult := stub.GetQueryResult(args[0])
+		if errGetQueryResult != nil {		//Most commonly a malformed Mango selector - a User-caused error, not a ledger failure
+			return nil, newAssetError(errCodeInvalidQuery, errorHeader+"Failed to execute rich query with selector '"+args[0]+"'. Details: "+errGetQueryResult.Error(), map[string]interface{}{"selector": args[0]})
+		}
+		defer resultsIterator.Close()
+
+		var matchingUsers []user
+		for resultsIterator.HasNext() {
+			queryResponse, errNext := resultsIterator.Next()
+			if errNext != nil {
+				panic(errorHeader + "Failed to iterate over rich query results. Details: " + errNext.Error())
+			}
+			var matchedUser user
+			errUnmarshalMatchedUser := json.Unmarshal(queryResponse.Value, &matchedUser)
+			if errUnmarshalMatchedUser != nil {
+				panic(errorHeader + "Failed to unmarshal User Information from query result. Details: " + errUnmarshalMatchedUser.Error())
+			}
+			matchingUsers = append(matchingUsers, matchedUser)
+		}
+
+		matchingUsersAsBytes, errMarshalMatchingUsers := json.Marshal(matchingUsers)
+		if errMarshalMatchingUsers != nil {
+			panic(errorHeader + "Failed to marshal matching Users. Details: " + errMarshalMatchingUsers.Error())
+		}
+		logger.Debug("queryassets matched " + strconv.Itoa(len(matchingUsers)) + " User(s).")
+		return matchingUsersAsBytes, nil
+	} else if function == "gethistory" {			//Ordered audit trail of modifications to a User's PUBLIC attributes (id, role, frozen) only.
+		// Asset Balance is confidential and lives only in the collAssetBalances private data collection (see getAssetBalance) -
+		// GetHistoryForKey only sees the public "user" composite key, so a transfer, mint or burn, which changes nothing but the
+		// balance, produces no entry here. This is not a balance audit trail.
+		const errorHeader = "ERROR: Source: Query - gethistory. "
+		if len(args) != 1 {				//Validate the number of arguments
+			return nil, newAssetError(errCodeInvalidArgument, errorHeader+"Incorrect number of arguments - expecting 1 (User ID).", nil)
+		}
+		userId := args[0]
+		userKey, errUserKey := t.userKey(stub, userId)
+		if errUserKey != nil {
+			panic(errorHeader + "Failed to build composite key for User ID '" + userId + "'. Details: " + errUserKey.Error())
+		}
+		historyIterator, errGetHistory := stub.GetHistoryForKey(userKey)
+		if errGetHistory != nil {		//Most commonly an unknown/never-joined User ID - a User-caused error, not a ledger failure
+			return nil, newAssetError(errCodeInvalidQuery, errorHeader+"Failed to get History for User ID '"+userId+"'. Details: "+errGetHistory.Error(), map[string]interface{}{"userId": userId})
+		}
+		defer historyIterator.Close()
+
+		type historyEntry struct {
+			TxId		string	`json:"txId"`
+			Timestamp	int64	`json:"timestamp"`
+			IsDelete	bool	`json:"isDelete"`
+			Value		string	`json:"value"`
+		}
+		var history []historyEntry
+		for historyIterator.HasNext() {
+			modification, errNext := historyIterator.Next()
+			if errNext != nil {
+				panic(errorHeader + "Failed to iterate over History for User ID '" + userId + "'. Details: " + errNext.Error())
+			}
+			history = append(history, historyEntry{
+				TxId:		modification.TxId,
+				Timestamp:	modification.Timestamp.GetSeconds(),
+				IsDelete:	modification.IsDelete,
+				Value:		string(modification.Value),
+			})
+		}
+
+		historyAsBytes, errMarshalHistory := json.Marshal(history)
+		if errMarshalHistory != nil {
+			panic(errorHeader + "Failed to marshal History for User ID '" + userId + "'. Details: " + errMarshalHistory.Error())
+		}
+		logger.Debug("gethistory returned " + strconv.Itoa(len(history)) + " modification(s) for User ID '" + userId + "'.")
+		return historyAsBytes, nil
 	}
-	
-	fmt.Println("Query() did not find function name: " + function)			//Log error
-	return nil, errors.New("Query() received unknown function: " + function)
+
+	logger.Warning("Query() did not find function name: " + function)
+	return nil, newAssetError(errCodeUnknownFunction, "Query() received unknown function: "+function, map[string]interface{}{"function": function})
 }
\ No newline at end of file